@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FormField is one field of a -form request: either a literal value, or a
+// file to be streamed in as a multipart file part (when FilePath is set).
+type FormField struct {
+	Name     string
+	Value    string
+	FilePath string
+}
+
+// parseFormFields parses a -form flag value like "field=value,file=@/path".
+// A value prefixed with "@" names a file to stream in as that field.
+func parseFormFields(raw string) ([]FormField, error) {
+	var fields []FormField
+	for _, pair := range splitAndTrim(raw, ",") {
+		kv := splitAndTrim(pair, "=")
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("invalid -form field %q, expected field=value or field=@file", pair)
+		}
+		name, value := kv[0], kv[1]
+		if strings.HasPrefix(value, "@") {
+			fields = append(fields, FormField{Name: name, FilePath: value[1:]})
+		} else {
+			fields = append(fields, FormField{Name: name, Value: value})
+		}
+	}
+	return fields, nil
+}
+
+// newMultipartBody streams fields into a multipart/form-data body using
+// io.Pipe, so file parts are read and written as they go rather than
+// buffered entirely in memory. The caller must close the returned reader.
+func newMultipartBody(fields []FormField) (io.ReadCloser, string, error) {
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	contentType := mw.FormDataContentType()
+
+	go func() {
+		err := writeMultipartFields(mw, fields)
+		if closeErr := mw.Close(); err == nil {
+			err = closeErr
+		}
+		pw.CloseWithError(err)
+	}()
+
+	return pr, contentType, nil
+}
+
+func writeMultipartFields(mw *multipart.Writer, fields []FormField) error {
+	for _, f := range fields {
+		if f.FilePath == "" {
+			if err := mw.WriteField(f.Name, f.Value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		file, err := os.Open(f.FilePath)
+		if err != nil {
+			return fmt.Errorf("failed to open form file %s: %w", f.FilePath, err)
+		}
+		part, err := mw.CreatePart(filePartHeader(f.Name, f.FilePath))
+		if err != nil {
+			file.Close()
+			return err
+		}
+		_, copyErr := io.Copy(part, file)
+		file.Close()
+		if copyErr != nil {
+			return fmt.Errorf("failed to stream form file %s: %w", f.FilePath, copyErr)
+		}
+	}
+	return nil
+}
+
+// filePartHeader builds the MIME header for a file part, detecting its
+// Content-Type from the file extension and falling back to
+// application/octet-stream when the extension is unknown.
+func filePartHeader(fieldName, filePath string) textproto.MIMEHeader {
+	contentType := mime.TypeByExtension(filepath.Ext(filePath))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	h := make(textproto.MIMEHeader)
+	h.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, fieldName, filepath.Base(filePath)))
+	h.Set("Content-Type", contentType)
+	return h
+}
+
+// buildRequestBody returns the POST body, Content-Type and Content-Length
+// for cfg's configured body source. contentLength is -1 when the body is
+// streamed and its size isn't known upfront (multipart form uploads), in
+// which case the caller should leave http.Request.ContentLength unset so
+// net/http falls back to chunked transfer encoding. A nil body means GET.
+func buildRequestBody(cfg *Config) (body io.ReadCloser, contentType string, contentLength int64, err error) {
+	switch {
+	case len(cfg.Form) > 0:
+		body, contentType, err = newMultipartBody(cfg.Form)
+		return body, contentType, -1, err
+
+	case cfg.BodyFromStdin:
+		return io.NopCloser(bytes.NewReader(cfg.stdinBody)), cfg.RequestType, int64(len(cfg.stdinBody)), nil
+
+	case cfg.PostFile != "":
+		file, err := os.Open(cfg.PostFile)
+		if err != nil {
+			return nil, "", 0, fmt.Errorf("failed to open POST file: %w", err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			file.Close()
+			return nil, "", 0, fmt.Errorf("failed to stat POST file: %w", err)
+		}
+		return file, cfg.RequestType, info.Size(), nil
+
+	default:
+		return nil, "", 0, nil
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"testing"
+)
+
+func TestParseFormFields(t *testing.T) {
+	fields, err := parseFormFields("name=value, file=@/tmp/upload.bin")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(fields) != 2 {
+		t.Fatalf("expected 2 fields, got %d", len(fields))
+	}
+	if fields[0].Name != "name" || fields[0].Value != "value" || fields[0].FilePath != "" {
+		t.Errorf("unexpected literal field: %+v", fields[0])
+	}
+	if fields[1].Name != "file" || fields[1].FilePath != "/tmp/upload.bin" {
+		t.Errorf("unexpected file field: %+v", fields[1])
+	}
+}
+
+func TestParseFormFieldsInvalid(t *testing.T) {
+	if _, err := parseFormFields("noequalssign"); err == nil {
+		t.Error("expected error for field without '='")
+	}
+}
+
+func TestNewMultipartBodyStreamsFields(t *testing.T) {
+	dir := t.TempDir()
+	filePath := dir + "/upload.json"
+	if err := os.WriteFile(filePath, []byte(`{"ok":true}`), 0o644); err != nil {
+		t.Fatalf("failed to write temp file: %v", err)
+	}
+
+	fields := []FormField{
+		{Name: "note", Value: "hello"},
+		{Name: "payload", FilePath: filePath},
+	}
+
+	body, contentType, err := newMultipartBody(fields)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer body.Close()
+
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("failed to parse content type %q: %v", contentType, err)
+	}
+
+	reader := multipart.NewReader(body, params["boundary"])
+	seen := map[string]string{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error reading part: %v", err)
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatalf("unexpected error reading part body: %v", err)
+		}
+		seen[part.FormName()] = string(data)
+	}
+
+	if seen["note"] != "hello" {
+		t.Errorf("expected note field 'hello', got %q", seen["note"])
+	}
+	if seen["payload"] != `{"ok":true}` {
+		t.Errorf("expected payload field file contents, got %q", seen["payload"])
+	}
+}
+
+func TestBuildRequestBodyGET(t *testing.T) {
+	cfg := &Config{}
+	body, contentType, length, err := buildRequestBody(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if body != nil || contentType != "" || length != 0 {
+		t.Errorf("expected empty GET body, got body=%v contentType=%q length=%d", body, contentType, length)
+	}
+}
+
+func TestBuildRequestBodyStdin(t *testing.T) {
+	cfg := &Config{BodyFromStdin: true, RequestType: "text/plain", stdinBody: []byte("hello")}
+	body, contentType, length, err := buildRequestBody(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer body.Close()
+	if contentType != "text/plain" || length != 5 {
+		t.Errorf("expected contentType 'text/plain' length 5, got %q %d", contentType, length)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("expected body 'hello', got %q", data)
+	}
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	gcecompute "cloud.google.com/go/compute/apiv1"
+	computepb "cloud.google.com/go/compute/apiv1/computepb"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	armcompute "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute/v5"
+	armnetwork "github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork/v4"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"google.golang.org/api/iterator"
+)
+
+// InstanceSource discovers the set of target instances to fan requests out
+// to, abstracting over the cloud provider (and discovery mechanism) used.
+type InstanceSource interface {
+	Discover(ctx context.Context) ([]Result, error)
+}
+
+// newInstanceSource builds the InstanceSource selected by cfg.Provider,
+// validating that the provider-specific flags it needs were supplied.
+func newInstanceSource(ctx context.Context, awsCfg aws.Config, cfg *Config) (InstanceSource, error) {
+	switch cfg.Provider {
+	case "aws-asg", "":
+		if cfg.ASGName == "" {
+			return nil, fmt.Errorf("-asg-name is required for provider aws-asg")
+		}
+		return &ASGInstanceSource{client: autoscaling.NewFromConfig(awsCfg), ec2Client: ec2.NewFromConfig(awsCfg), asgName: cfg.ASGName}, nil
+	case "aws-ec2":
+		if cfg.Tag == "" {
+			return nil, fmt.Errorf("-tag is required for provider aws-ec2")
+		}
+		return &EC2TagInstanceSource{client: ec2.NewFromConfig(awsCfg), tag: cfg.Tag}, nil
+	case "azure-vmss":
+		if cfg.VMSSName == "" || cfg.ResourceGroup == "" || cfg.SubscriptionID == "" {
+			return nil, fmt.Errorf("-vmss-name, -resource-group and -subscription-id are required for provider azure-vmss")
+		}
+		cred, err := azidentity.NewDefaultAzureCredential(nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to obtain Azure credentials: %w", err)
+		}
+		vmClient, err := armcompute.NewVirtualMachineScaleSetVMsClient(cfg.SubscriptionID, cred, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Azure VMSS client: %w", err)
+		}
+		nicClient, err := armnetwork.NewInterfacesClient(cfg.SubscriptionID, cred, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create Azure network interfaces client: %w", err)
+		}
+		return &AzureVMSSInstanceSource{
+			vmClient:      vmClient,
+			nicClient:     nicClient,
+			resourceGroup: cfg.ResourceGroup,
+			vmssName:      cfg.VMSSName,
+		}, nil
+	case "gcp-mig":
+		if cfg.MIGName == "" || cfg.GCPProject == "" || cfg.GCPZone == "" {
+			return nil, fmt.Errorf("-mig-name, -gcp-project and -gcp-zone are required for provider gcp-mig")
+		}
+		migClient, err := gcecompute.NewInstanceGroupManagersRESTClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCP instance group managers client: %w", err)
+		}
+		instClient, err := gcecompute.NewInstancesRESTClient(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCP instances client: %w", err)
+		}
+		return &GCPMIGInstanceSource{
+			migClient:  migClient,
+			instClient: instClient,
+			project:    cfg.GCPProject,
+			zone:       cfg.GCPZone,
+			migName:    cfg.MIGName,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q (want aws-asg, aws-ec2, azure-vmss or gcp-mig)", cfg.Provider)
+	}
+}
+
+// ASGInstanceSource discovers instances by membership in an AWS Auto Scaling
+// Group. This is the original (and default) discovery mechanism.
+type ASGInstanceSource struct {
+	client    *autoscaling.Client
+	ec2Client *ec2.Client
+	asgName   string
+}
+
+func (s *ASGInstanceSource) Discover(ctx context.Context) ([]Result, error) {
+	instanceIDs, err := getASGInstanceIDs(ctx, s.client, s.asgName)
+	if err != nil {
+		return nil, err
+	}
+	return getInstanceMetadata(ctx, s.ec2Client, instanceIDs)
+}
+
+// EC2TagInstanceSource discovers instances directly via an EC2 tag filter
+// (key=value), with no ASG involved. Useful for fleets managed outside of
+// Auto Scaling, e.g. Spot Fleets or manually tagged instances.
+type EC2TagInstanceSource struct {
+	client *ec2.Client
+	tag    string
+}
+
+func (s *EC2TagInstanceSource) Discover(ctx context.Context) ([]Result, error) {
+	kv := strings.SplitN(s.tag, "=", 2)
+	if len(kv) != 2 {
+		return nil, fmt.Errorf("invalid -tag %q, expected key=value", s.tag)
+	}
+
+	resp, err := s.client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
+		Filters: []ec2types.Filter{
+			{Name: aws.String("tag:" + kv[0]), Values: []string{kv[1]}},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Result
+	for _, r := range resp.Reservations {
+		for _, inst := range r.Instances {
+			if inst.PrivateIpAddress == nil {
+				continue
+			}
+			state := "unknown"
+			if inst.State != nil && inst.State.Name != "" {
+				state = string(inst.State.Name)
+			}
+			results = append(results, Result{
+				InstanceID:    *inst.InstanceId,
+				IP:            *inst.PrivateIpAddress,
+				LaunchTime:    aws.ToTime(inst.LaunchTime),
+				InstanceState: state,
+			})
+		}
+	}
+	return results, nil
+}
+
+// AzureVMSSInstanceSource discovers instances by membership in an Azure
+// Virtual Machine Scale Set.
+type AzureVMSSInstanceSource struct {
+	vmClient      *armcompute.VirtualMachineScaleSetVMsClient
+	nicClient     *armnetwork.InterfacesClient
+	resourceGroup string
+	vmssName      string
+}
+
+func (s *AzureVMSSInstanceSource) Discover(ctx context.Context) ([]Result, error) {
+	var results []Result
+	expand := string(armcompute.InstanceViewTypesInstanceView)
+	pager := s.vmClient.NewListPager(s.resourceGroup, s.vmssName, &armcompute.VirtualMachineScaleSetVMsClientListOptions{Expand: &expand})
+	for pager.More() {
+		page, err := pager.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list VMSS instances: %w", err)
+		}
+		for _, vm := range page.Value {
+			result := Result{InstanceState: "unknown"}
+			if vm.InstanceID != nil {
+				result.InstanceID = *vm.InstanceID
+			}
+			result.InstanceState = powerState(vm)
+
+			ip, err := s.privateIP(ctx, vm)
+			if err != nil {
+				result.Error = err
+			} else {
+				result.IP = ip
+			}
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// powerState derives the instance's run state from its PowerState instance
+// view status (e.g. "PowerState/running"), not ProvisioningState, which
+// reflects the last control-plane operation (Creating/Updating/Succeeded/
+// Failed/Deleting) rather than whether the VM is actually running.
+func powerState(vm *armcompute.VirtualMachineScaleSetVM) string {
+	if vm.Properties == nil || vm.Properties.InstanceView == nil {
+		return "unknown"
+	}
+	for _, status := range vm.Properties.InstanceView.Statuses {
+		if status.Code == nil {
+			continue
+		}
+		code := *status.Code
+		const prefix = "PowerState/"
+		if strings.HasPrefix(code, prefix) {
+			return strings.ToLower(strings.TrimPrefix(code, prefix))
+		}
+	}
+	return "unknown"
+}
+
+// privateIP fetches the primary private IP of a scale set VM's first network
+// interface. Azure does not return IP addresses inline on the VM resource,
+// so this costs one extra API call per instance.
+func (s *AzureVMSSInstanceSource) privateIP(ctx context.Context, vm *armcompute.VirtualMachineScaleSetVM) (string, error) {
+	if vm.Properties == nil || vm.Properties.NetworkProfile == nil || len(vm.Properties.NetworkProfile.NetworkInterfaces) == 0 {
+		return "", fmt.Errorf("instance has no network interfaces")
+	}
+	nicID := vm.Properties.NetworkProfile.NetworkInterfaces[0].ID
+	if nicID == nil {
+		return "", fmt.Errorf("instance network interface has no ID")
+	}
+	nicName := (*nicID)[strings.LastIndex(*nicID, "/")+1:]
+
+	resp, err := s.nicClient.GetVirtualMachineScaleSetNetworkInterface(ctx, s.resourceGroup, s.vmssName, *vm.InstanceID, nicName, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch network interface %s: %w", nicName, err)
+	}
+	if resp.Properties == nil {
+		return "", fmt.Errorf("network interface %s has no properties", nicName)
+	}
+	for _, cfg := range resp.Properties.IPConfigurations {
+		if cfg.Properties != nil && cfg.Properties.PrivateIPAddress != nil {
+			return *cfg.Properties.PrivateIPAddress, nil
+		}
+	}
+	return "", fmt.Errorf("network interface %s has no private IP", nicName)
+}
+
+// GCPMIGInstanceSource discovers instances by membership in a GCP Managed
+// Instance Group.
+type GCPMIGInstanceSource struct {
+	migClient  *gcecompute.InstanceGroupManagersClient
+	instClient *gcecompute.InstancesClient
+	project    string
+	zone       string
+	migName    string
+}
+
+func (s *GCPMIGInstanceSource) Discover(ctx context.Context) ([]Result, error) {
+	var results []Result
+	it := s.migClient.ListManagedInstances(ctx, &computepb.ListManagedInstancesInstanceGroupManagersRequest{
+		Project:              s.project,
+		Zone:                 s.zone,
+		InstanceGroupManager: s.migName,
+	})
+	for {
+		managed, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to list managed instances: %w", err)
+		}
+
+		name := managed.GetInstance()
+		name = name[strings.LastIndex(name, "/")+1:]
+		status := strings.ToLower(managed.GetInstanceStatus())
+
+		inst, err := s.instClient.Get(ctx, &computepb.GetInstanceRequest{
+			Project:  s.project,
+			Zone:     s.zone,
+			Instance: name,
+		})
+		result := Result{InstanceID: name, InstanceState: status}
+		if err != nil {
+			result.Error = fmt.Errorf("failed to fetch instance %s: %w", name, err)
+			results = append(results, result)
+			continue
+		}
+		if len(inst.NetworkInterfaces) > 0 && inst.NetworkInterfaces[0].NetworkIP != nil {
+			result.IP = inst.NetworkInterfaces[0].GetNetworkIP()
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -9,6 +9,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 
@@ -16,48 +18,172 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"golang.org/x/time/rate"
 )
 
+// supportedProviders lists the valid values for the -provider flag.
+var supportedProviders = []string{"aws-asg", "aws-ec2", "azure-vmss", "gcp-mig"}
+
+// supportedOutputFormats lists the valid values for the -output flag.
+var supportedOutputFormats = []string{"table", "json", "ndjson", "csv"}
+
+// supportedTransports lists the valid values for the -via flag.
+var supportedTransports = []string{"direct", "ssm", "bastion"}
+
+// Timing is a breakdown of how long each phase of an HTTP request took,
+// captured via httptrace.ClientTrace.
+type Timing struct {
+	DNS     time.Duration
+	Connect time.Duration
+	TLS     time.Duration
+	TTFB    time.Duration
+	Total   time.Duration
+}
+
 type Result struct {
 	InstanceID    string
 	IP            string
 	LaunchTime    time.Time
 	ResponseTime  time.Duration
+	HTTPStatus    int
+	Timing        Timing
+	Attempts      []AttemptResult
 	Error         error
 	InstanceState string
+
+	// body holds the response body, only populated when cfg.ExpectBodyRegex
+	// is set so watch mode can evaluate it. Never surfaced in any output
+	// format.
+	body []byte
+
+	// dialAddr overrides the host:port doAttempt connects to, used by the
+	// ssm transport to point at a locally forwarded port instead of IP.
+	// Empty means connect to IP:cfg.Port as usual.
+	dialAddr string
 }
 
 type Config struct {
-	ASGName     string
-	Region      string
-	Path        string
-	Port        string
-	TLSEnabled  bool
-	PostFile    string
-	RequestType string
-	Timeout     time.Duration
-	Headers     map[string]string
+	Provider       string
+	ASGName        string
+	Tag            string
+	VMSSName       string
+	ResourceGroup  string
+	SubscriptionID string
+	MIGName        string
+	GCPProject     string
+	GCPZone        string
+	Region         string
+	Path           string
+	Port           string
+	TLSEnabled     bool
+	PostFile       string
+	Form           []FormField
+	BodyFromStdin  bool
+	RequestType    string
+	Timeout        time.Duration
+	Headers        map[string]string
+	OutputFormat   string
+
+	Concurrency     int
+	RPS             float64
+	Retries         int
+	RetryBackoff    time.Duration
+	RetryBackoffMax time.Duration
+	RetryStatuses   string
+
+	Watch           time.Duration
+	ExpectStatus    int
+	ExpectBodyRegex string
+	FailThreshold   int
+	MetricsAddr     string
+
+	Via                      string
+	BastionAddr              string
+	SSHKeyPath               string
+	InsecureSkipHostKeyCheck bool
+
+	// retryStatusSet is RetryStatuses parsed into a set for fast lookup,
+	// populated by parseFlags alongside Headers and Form.
+	retryStatusSet map[int]bool
+
+	// expectBodyRegex is ExpectBodyRegex compiled once by parseFlags so
+	// watch mode doesn't recompile it every cycle.
+	expectBodyRegex *regexp.Regexp
+
+	// transport is the requestTransport selected by Via, populated by main
+	// (not parseFlags) since dialing SSM/SSH is an I/O side effect. A nil
+	// transport means the default direct connection to inst.IP.
+	transport requestTransport
+
+	// stdinBody holds the body read from stdin when BodyFromStdin is set.
+	// It is populated by main (not parseFlags) since reading stdin is an
+	// I/O side effect, not flag parsing.
+	stdinBody []byte
 }
 
 func parseFlags(fs *flag.FlagSet, args []string) (*Config, error) {
 	cfg := &Config{}
 	var headersRaw string
-	fs.StringVar(&cfg.ASGName, "asg-name", "", "Name of the Auto Scaling Group (required)")
-	fs.StringVar(&cfg.Region, "region", "", "AWS region (required)")
+	fs.StringVar(&cfg.Provider, "provider", "aws-asg", "Instance source provider: aws-asg, aws-ec2, azure-vmss or gcp-mig (default: aws-asg)")
+	fs.StringVar(&cfg.ASGName, "asg-name", "", "Name of the Auto Scaling Group (required for -provider aws-asg)")
+	fs.StringVar(&cfg.Tag, "tag", "", "EC2 tag filter key=value (required for -provider aws-ec2)")
+	fs.StringVar(&cfg.VMSSName, "vmss-name", "", "Name of the Azure VM Scale Set (required for -provider azure-vmss)")
+	fs.StringVar(&cfg.ResourceGroup, "resource-group", "", "Azure resource group containing the VMSS (required for -provider azure-vmss)")
+	fs.StringVar(&cfg.SubscriptionID, "subscription-id", "", "Azure subscription ID (required for -provider azure-vmss)")
+	fs.StringVar(&cfg.MIGName, "mig-name", "", "Name of the GCP Managed Instance Group (required for -provider gcp-mig)")
+	fs.StringVar(&cfg.GCPProject, "gcp-project", "", "GCP project ID (required for -provider gcp-mig)")
+	fs.StringVar(&cfg.GCPZone, "gcp-zone", "", "GCP zone the MIG lives in (required for -provider gcp-mig)")
+	fs.StringVar(&cfg.Region, "region", "", "AWS region (required for -provider aws-asg and aws-ec2)")
 	fs.StringVar(&cfg.Path, "path", "/", "HTTP path to call on each instance (default: /)")
 	fs.StringVar(&cfg.Port, "port", "80", "Port to use for the HTTP request (default: 80)")
 	fs.BoolVar(&cfg.TLSEnabled, "tls", false, "Enable TLS (use HTTPS instead of HTTP) (default: false)")
 	fs.StringVar(&cfg.PostFile, "post", "", "File to POST as request body (if set, POST is used instead of GET) (eg: some-request.json)")
+	var formRaw string
+	fs.StringVar(&formRaw, "form", "", "Comma-separated multipart/form-data fields (field=value,file=@/path/to/file)")
+	fs.BoolVar(&cfg.BodyFromStdin, "body-from-stdin", false, "Read the POST body from stdin instead of -post/-form (default: false)")
 	fs.StringVar(&cfg.RequestType, "request-type", "application/json", "Content-Type for the request (default: application/json)")
 	fs.DurationVar(&cfg.Timeout, "timeout", 3*time.Second, "HTTP request timeout (default: 3s, example: 1.5s, 500ms, 2m)")
 	fs.StringVar(&headersRaw, "headers", "", "Comma-separated list of headers (key=value,key2=value2)")
+	fs.StringVar(&cfg.OutputFormat, "output", "table", "Output format: table, json, ndjson or csv (default: table)")
+	fs.IntVar(&cfg.Concurrency, "concurrency", 0, "Maximum number of concurrent requests in flight (default: 0, unlimited)")
+	fs.Float64Var(&cfg.RPS, "rps", 0, "Maximum requests per second across all instances, via a token-bucket limiter (default: 0, unlimited)")
+	fs.IntVar(&cfg.Retries, "retries", 0, "Number of retries on network errors or retryable HTTP status codes (default: 0)")
+	fs.DurationVar(&cfg.RetryBackoff, "retry-backoff", 200*time.Millisecond, "Base retry backoff, doubled on each attempt (default: 200ms)")
+	fs.DurationVar(&cfg.RetryBackoffMax, "retry-backoff-max", 10*time.Second, "Cap on retry backoff (default: 10s)")
+	fs.StringVar(&cfg.RetryStatuses, "retry-status", defaultRetryStatuses, "Comma-separated HTTP status codes/ranges to retry on (default: "+defaultRetryStatuses+")")
+	fs.DurationVar(&cfg.Watch, "watch", 0, "Poll continuously at this interval instead of running once (default: 0, disabled, example: 30s)")
+	fs.IntVar(&cfg.ExpectStatus, "expect-status", 0, "HTTP status a response must match to be considered healthy (default: 0, any status without a request error is healthy)")
+	fs.StringVar(&cfg.ExpectBodyRegex, "expect-body-regex", "", "Regular expression the response body must match to be considered healthy (default: none)")
+	fs.IntVar(&cfg.FailThreshold, "fail-threshold", 3, "Consecutive failed cycles for any instance before -watch exits non-zero (default: 3)")
+	fs.StringVar(&cfg.MetricsAddr, "metrics-addr", "", "Address to serve Prometheus metrics on during -watch, e.g. :9090 (default: none, disabled)")
+	fs.StringVar(&cfg.Via, "via", "direct", "Transport to reach instances: direct, ssm or bastion (default: direct)")
+	fs.StringVar(&cfg.BastionAddr, "bastion", "", "SSH jump host as user@host[:port] (required for -via bastion)")
+	fs.StringVar(&cfg.SSHKeyPath, "ssh-key", "", "Private key file for -via bastion (default: none, use ssh-agent via SSH_AUTH_SOCK)")
+	fs.BoolVar(&cfg.InsecureSkipHostKeyCheck, "insecure-skip-host-key-check", false, "Accept any bastion host key instead of verifying against ~/.ssh/known_hosts (default: false)")
 	err := fs.Parse(args)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse flags: %w", err)
 	}
 
-	if cfg.ASGName == "" || cfg.Region == "" {
-		return nil, fmt.Errorf("asg-name and region are required")
+	switch cfg.Provider {
+	case "aws-asg", "aws-ec2":
+		if cfg.Region == "" {
+			return nil, fmt.Errorf("region is required for provider %s", cfg.Provider)
+		}
+	case "azure-vmss", "gcp-mig":
+		// Region is AWS-specific; these providers validate their own flags.
+	default:
+		return nil, fmt.Errorf("unknown provider %q (want %s)", cfg.Provider, strings.Join(supportedProviders, ", "))
+	}
+
+	switch cfg.OutputFormat {
+	case "table", "json", "ndjson", "csv":
+	default:
+		return nil, fmt.Errorf("unknown output format %q (want %s)", cfg.OutputFormat, strings.Join(supportedOutputFormats, ", "))
+	}
+
+	if cfg.Provider == "aws-asg" && cfg.ASGName == "" {
+		return nil, fmt.Errorf("asg-name is required for provider aws-asg")
 	}
 
 	if cfg.Path == "" || cfg.Path[0] != '/' {
@@ -70,6 +196,71 @@ func parseFlags(fs *flag.FlagSet, args []string) (*Config, error) {
 		}
 	}
 
+	if formRaw != "" {
+		fields, err := parseFormFields(formRaw)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			if f.FilePath == "" {
+				continue
+			}
+			if _, err := os.Stat(f.FilePath); os.IsNotExist(err) {
+				return nil, fmt.Errorf("form file does not exist: %s", f.FilePath)
+			}
+		}
+		cfg.Form = fields
+	}
+
+	bodySources := 0
+	for _, set := range []bool{cfg.PostFile != "", len(cfg.Form) > 0, cfg.BodyFromStdin} {
+		if set {
+			bodySources++
+		}
+	}
+	if bodySources > 1 {
+		return nil, fmt.Errorf("only one of -post, -form and -body-from-stdin may be set")
+	}
+
+	if cfg.Concurrency < 0 {
+		return nil, fmt.Errorf("concurrency must not be negative")
+	}
+	if cfg.RPS < 0 {
+		return nil, fmt.Errorf("rps must not be negative")
+	}
+	if cfg.Retries < 0 {
+		return nil, fmt.Errorf("retries must not be negative")
+	}
+	statusSet, err := parseStatusSet(cfg.RetryStatuses)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -retry-status: %w", err)
+	}
+	cfg.retryStatusSet = statusSet
+
+	if cfg.Watch < 0 {
+		return nil, fmt.Errorf("watch interval must not be negative")
+	}
+	if cfg.FailThreshold <= 0 {
+		return nil, fmt.Errorf("fail-threshold must be positive")
+	}
+	if cfg.ExpectBodyRegex != "" {
+		re, err := regexp.Compile(cfg.ExpectBodyRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -expect-body-regex: %w", err)
+		}
+		cfg.expectBodyRegex = re
+	}
+
+	switch cfg.Via {
+	case "direct", "ssm":
+	case "bastion":
+		if cfg.BastionAddr == "" {
+			return nil, fmt.Errorf("-bastion is required for -via bastion")
+		}
+	default:
+		return nil, fmt.Errorf("unknown transport %q (want %s)", cfg.Via, strings.Join(supportedTransports, ", "))
+	}
+
 	cfg.Headers = make(map[string]string)
 	if headersRaw != "" {
 		headerPairs := splitAndTrim(headersRaw, ",")
@@ -99,106 +290,113 @@ func main() {
 		log.Fatalf("Error: %v", err)
 	}
 
-	awsCfg, err := config.LoadDefaultConfig(context.Background(), config.WithRegion(cfg.Region))
+	ctx := context.Background()
+
+	var awsCfg aws.Config
+	if cfg.Provider == "aws-asg" || cfg.Provider == "aws-ec2" || cfg.Via == "ssm" {
+		awsCfg, err = config.LoadDefaultConfig(ctx, config.WithRegion(cfg.Region))
+		if err != nil {
+			log.Fatalf("Error loading AWS config: %v", err)
+		}
+	}
+
+	source, err := newInstanceSource(ctx, awsCfg, cfg)
 	if err != nil {
-		log.Fatalf("Error loading AWS config: %v", err)
+		log.Fatalf("Error: %v", err)
 	}
 
-	instanceIDs, err := getASGInstanceIDs(awsCfg, cfg.ASGName)
+	instances, err := source.Discover(ctx)
 	if err != nil {
-		log.Fatalf("Error getting instances: %v", err)
+		log.Fatalf("Error discovering instances: %v", err)
 	}
 
-	instances, err := getInstanceMetadata(awsCfg, instanceIDs)
+	transport, err := newTransport(awsCfg, cfg)
 	if err != nil {
-		log.Fatalf("Error retrieving instance metadata: %v", err)
+		log.Fatalf("Error setting up -via %s: %v", cfg.Via, err)
+	}
+	cfg.transport = transport
+	if transport != nil {
+		defer transport.Close()
+	}
+
+	if cfg.BodyFromStdin {
+		cfg.stdinBody, err = io.ReadAll(os.Stdin)
+		if err != nil {
+			log.Fatalf("Error reading POST body from stdin: %v", err)
+		}
+	}
+
+	if cfg.Watch > 0 {
+		if err := runWatch(ctx, cfg, source, instances); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
 	}
 
 	results := makeRequests(cfg, instances)
-	printResults(results)
+	if err := printResults(cfg.OutputFormat, results); err != nil {
+		log.Fatalf("Error printing results: %v", err)
+	}
 }
 
 func makeRequests(cfg *Config, instances []Result) []Result {
 	var wg sync.WaitGroup
 	resultsChan := make(chan Result, len(instances))
 
+	var sem chan struct{}
+	if cfg.Concurrency > 0 {
+		sem = make(chan struct{}, cfg.Concurrency)
+	}
+
+	var limiter *rate.Limiter
+	if cfg.RPS > 0 {
+		burst := int(cfg.RPS)
+		if burst < 1 {
+			burst = 1
+		}
+		limiter = rate.NewLimiter(rate.Limit(cfg.RPS), burst)
+	}
+
+	client := &http.Client{Timeout: cfg.Timeout}
+
 	for i := range instances {
 		inst := instances[i]
-		if inst.InstanceState != "running" {
-			inst.Error = nil
-			inst.ResponseTime = 0
+		if inst.Error != nil || inst.InstanceState != "running" {
 			resultsChan <- Result{
 				InstanceID:    inst.InstanceID,
 				IP:            inst.IP,
 				LaunchTime:    inst.LaunchTime,
-				ResponseTime:  0,
-				Error:         nil,
 				InstanceState: inst.InstanceState,
+				Error:         inst.Error,
 			}
 			continue
 		}
 		wg.Add(1)
 		go func(inst Result) {
 			defer wg.Done()
-			start := time.Now()
-			client := http.Client{Timeout: cfg.Timeout}
-			protocol := "http"
-			if cfg.TLSEnabled {
-				protocol = "https"
+			if sem != nil {
+				sem <- struct{}{}
+				defer func() { <-sem }()
 			}
-			url := fmt.Sprintf("%s://%s:%s%s", protocol, inst.IP, cfg.Port, cfg.Path)
 
-			var resp *http.Response
-			if cfg.PostFile != "" {
-				data, err := os.ReadFile(cfg.PostFile)
+			reqClient, reqInst := client, inst
+			if cfg.transport != nil {
+				c, i, cleanup, err := cfg.transport.Prepare(context.Background(), inst)
 				if err != nil {
-					inst.Error = fmt.Errorf("failed to read POST file: %w", err)
-					resultsChan <- inst
+					resultsChan <- Result{
+						InstanceID:    inst.InstanceID,
+						IP:            inst.IP,
+						LaunchTime:    inst.LaunchTime,
+						InstanceState: inst.InstanceState,
+						Error:         err,
+					}
 					return
 				}
-				req, err := http.NewRequest("POST", url, bytes.NewReader(data))
-				if err != nil {
-					inst.Error = err
-					resultsChan <- inst
-					return
-				}
-				req.Header.Set("Content-Type", cfg.RequestType)
-				for k, v := range cfg.Headers {
-					req.Header.Set(k, v)
-				}
-				resp, err = client.Do(req)
-				if err != nil {
-					inst.Error = err
-					resultsChan <- inst
-					return
-				}
-			} else {
-				req, err := http.NewRequest("GET", url, nil)
-				if err != nil {
-					inst.Error = err
-					resultsChan <- inst
-					return
-				}
-				for k, v := range cfg.Headers {
-					req.Header.Set(k, v)
-				}
-				resp, err = client.Do(req)
-				if err != nil {
-					inst.Error = err
-					resultsChan <- inst
-					return
-				}
-			}
-			defer resp.Body.Close()
-			_, err := io.Copy(io.Discard, resp.Body)
-			if err != nil {
-				inst.Error = fmt.Errorf("failed to read response body: %w", err)
-				resultsChan <- inst
-				return
+				defer cleanup()
+				reqClient, reqInst = c, i
 			}
-			inst.ResponseTime = time.Since(start)
-			inst.Error = nil
-			resultsChan <- inst
+
+			resultsChan <- performRequest(cfg, reqClient, limiter, reqInst)
 		}(inst)
 	}
 	wg.Wait()
@@ -211,29 +409,8 @@ func makeRequests(cfg *Config, instances []Result) []Result {
 	return results
 }
 
-func printResults(results []Result) {
-	fmt.Printf("\n%-20s %-15s %-25s %-12s %-15s %s\n", "Instance ID", "IP", "Launch Time", "State", "Resp Time", "Status")
-	for _, inst := range results {
-		status := "OK"
-		if inst.InstanceState != "running" {
-			status = "Skipped"
-		} else if inst.Error != nil {
-			status = inst.Error.Error()
-		}
-		fmt.Printf("%-20s %-15s %-25s %-12s %-15s %s\n",
-			inst.InstanceID,
-			inst.IP,
-			inst.LaunchTime.Format(time.RFC3339),
-			inst.InstanceState,
-			inst.ResponseTime,
-			status,
-		)
-	}
-}
-
-func getASGInstanceIDs(cfg aws.Config, asgName string) ([]string, error) {
-	client := autoscaling.NewFromConfig(cfg)
-	resp, err := client.DescribeAutoScalingGroups(context.Background(), &autoscaling.DescribeAutoScalingGroupsInput{
+func getASGInstanceIDs(ctx context.Context, client *autoscaling.Client, asgName string) ([]string, error) {
+	resp, err := client.DescribeAutoScalingGroups(ctx, &autoscaling.DescribeAutoScalingGroupsInput{
 		AutoScalingGroupNames: []string{asgName},
 	})
 	if err != nil {
@@ -251,9 +428,8 @@ func getASGInstanceIDs(cfg aws.Config, asgName string) ([]string, error) {
 	return ids, nil
 }
 
-func getInstanceMetadata(cfg aws.Config, instanceIDs []string) ([]Result, error) {
-	client := ec2.NewFromConfig(cfg)
-	resp, err := client.DescribeInstances(context.Background(), &ec2.DescribeInstancesInput{
+func getInstanceMetadata(ctx context.Context, client *ec2.Client, instanceIDs []string) ([]Result, error) {
+	resp, err := client.DescribeInstances(ctx, &ec2.DescribeInstancesInput{
 		InstanceIds: instanceIDs,
 	})
 	if err != nil {
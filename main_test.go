@@ -2,12 +2,18 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"flag"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
 )
 
 func TestParseFlagsDefaults(t *testing.T) {
@@ -90,7 +96,9 @@ func TestPrintResults(t *testing.T) {
 		close(done)
 	}()
 
-	printResults(results)
+	if err := printResults("table", results); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
 	w.Close()
 	<-done
 	os.Stdout = stdout
@@ -104,6 +112,106 @@ func TestPrintResults(t *testing.T) {
 	}
 }
 
+func TestParseFlagsDefaultProvider(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Provider != "aws-asg" {
+		t.Errorf("expected default provider 'aws-asg', got %q", cfg.Provider)
+	}
+}
+
+func TestParseFlagsUnknownProvider(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := parseFlags(fs, []string{"-provider", "openstack", "-tag", "k=v"})
+	if err == nil || !strings.Contains(err.Error(), "unknown provider") {
+		t.Errorf("expected unknown provider error, got %v", err)
+	}
+}
+
+func TestParseFlagsAWSEC2RequiresRegion(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := parseFlags(fs, []string{"-provider", "aws-ec2", "-tag", "k=v"})
+	if err == nil || !strings.Contains(err.Error(), "region is required") {
+		t.Errorf("expected region-required error, got %v", err)
+	}
+}
+
+func TestParseFlagsAzureVMSSSkipsRegion(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := parseFlags(fs, []string{"-provider", "azure-vmss", "-vmss-name", "vmss1", "-resource-group", "rg1", "-subscription-id", "sub1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.VMSSName != "vmss1" {
+		t.Errorf("expected VMSSName 'vmss1', got %q", cfg.VMSSName)
+	}
+}
+
+func TestNewInstanceSourceUnknownProvider(t *testing.T) {
+	cfg := &Config{Provider: "openstack"}
+	_, err := newInstanceSource(context.Background(), aws.Config{}, cfg)
+	if err == nil || !strings.Contains(err.Error(), "unknown provider") {
+		t.Errorf("expected unknown provider error, got %v", err)
+	}
+}
+
+func TestNewInstanceSourceAWSEC2MissingTag(t *testing.T) {
+	cfg := &Config{Provider: "aws-ec2"}
+	_, err := newInstanceSource(context.Background(), aws.Config{}, cfg)
+	if err == nil || !strings.Contains(err.Error(), "-tag is required") {
+		t.Errorf("expected -tag required error, got %v", err)
+	}
+}
+
+func TestParseFlagsDefaultOutputFormat(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.OutputFormat != "table" {
+		t.Errorf("expected default output format 'table', got %q", cfg.OutputFormat)
+	}
+}
+
+func TestParseFlagsUnknownOutputFormat(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2", "-output", "xml"})
+	if err == nil || !strings.Contains(err.Error(), "unknown output format") {
+		t.Errorf("expected unknown output format error, got %v", err)
+	}
+}
+
+func TestParseFlagsFormField(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2", "-form", "name=value"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.Form) != 1 || cfg.Form[0].Name != "name" || cfg.Form[0].Value != "value" {
+		t.Errorf("unexpected form fields: %+v", cfg.Form)
+	}
+}
+
+func TestParseFlagsFormFileNotExist(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2", "-form", "file=@/no/such/file"})
+	if err == nil || !strings.Contains(err.Error(), "form file does not exist") {
+		t.Errorf("expected form file does not exist error, got %v", err)
+	}
+}
+
+func TestParseFlagsMutuallyExclusiveBodySources(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2", "-form", "name=value", "-body-from-stdin"})
+	if err == nil || !strings.Contains(err.Error(), "only one of -post, -form and -body-from-stdin") {
+		t.Errorf("expected mutually exclusive body source error, got %v", err)
+	}
+}
+
 func TestMakeRequestsEmpty(t *testing.T) {
 	cfg := &Config{Timeout: 10 * time.Millisecond}
 	results := makeRequests(cfg, nil)
@@ -111,3 +219,124 @@ func TestMakeRequestsEmpty(t *testing.T) {
 		t.Errorf("expected 0 results, got %d", len(results))
 	}
 }
+
+func TestParseFlagsRetryDefaults(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Retries != 0 {
+		t.Errorf("expected default retries 0, got %d", cfg.Retries)
+	}
+	if !cfg.retryStatusSet[429] || !cfg.retryStatusSet[503] {
+		t.Errorf("expected default retry status set to include 429 and 503, got %v", cfg.retryStatusSet)
+	}
+}
+
+func TestParseFlagsNegativeConcurrency(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2", "-concurrency", "-1"})
+	if err == nil || !strings.Contains(err.Error(), "concurrency must not be negative") {
+		t.Errorf("expected negative concurrency error, got %v", err)
+	}
+}
+
+func TestParseFlagsWatchDefaults(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Watch != 0 {
+		t.Errorf("expected default watch interval 0, got %v", cfg.Watch)
+	}
+	if cfg.FailThreshold != 3 {
+		t.Errorf("expected default fail-threshold 3, got %d", cfg.FailThreshold)
+	}
+}
+
+func TestParseFlagsNegativeWatch(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2", "-watch", "-1s"})
+	if err == nil || !strings.Contains(err.Error(), "watch interval must not be negative") {
+		t.Errorf("expected negative watch error, got %v", err)
+	}
+}
+
+func TestParseFlagsInvalidFailThreshold(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2", "-fail-threshold", "0"})
+	if err == nil || !strings.Contains(err.Error(), "fail-threshold must be positive") {
+		t.Errorf("expected fail-threshold error, got %v", err)
+	}
+}
+
+func TestParseFlagsInvalidExpectBodyRegex(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2", "-expect-body-regex", "("})
+	if err == nil || !strings.Contains(err.Error(), "invalid -expect-body-regex") {
+		t.Errorf("expected invalid regex error, got %v", err)
+	}
+}
+
+func TestParseFlagsViaDefault(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.Via != "direct" {
+		t.Errorf("expected default transport 'direct', got %q", cfg.Via)
+	}
+}
+
+func TestParseFlagsUnknownTransport(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2", "-via", "vpn"})
+	if err == nil || !strings.Contains(err.Error(), "unknown transport") {
+		t.Errorf("expected unknown transport error, got %v", err)
+	}
+}
+
+func TestParseFlagsBastionRequiresAddr(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	_, err := parseFlags(fs, []string{"-asg-name", "asg1", "-region", "eu-west-2", "-via", "bastion"})
+	if err == nil || !strings.Contains(err.Error(), "-bastion is required") {
+		t.Errorf("expected bastion-required error, got %v", err)
+	}
+}
+
+func TestMakeRequestsRetriesOnServerError(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if n < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	host, port, _ := strings.Cut(strings.TrimPrefix(server.URL, "http://"), ":")
+	cfg := &Config{
+		Timeout:         time.Second,
+		Port:            port,
+		Path:            "/",
+		Retries:         2,
+		RetryBackoff:    time.Millisecond,
+		RetryBackoffMax: 10 * time.Millisecond,
+		retryStatusSet:  map[int]bool{503: true},
+	}
+	results := makeRequests(cfg, []Result{{InstanceID: "i-1", IP: host, InstanceState: "running"}})
+	if len(results) != 1 {
+		t.Fatalf("expected 1 result, got %d", len(results))
+	}
+	if results[0].HTTPStatus != http.StatusOK {
+		t.Errorf("expected final status 200, got %d", results[0].HTTPStatus)
+	}
+	if len(results[0].Attempts) != 3 {
+		t.Errorf("expected 3 attempts, got %d", len(results[0].Attempts))
+	}
+}
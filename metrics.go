@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// watchMetrics exposes the latest cycle's per-instance results as Prometheus
+// gauges, served via Handler on -metrics-addr. It is registered on its own
+// registry rather than prometheus.DefaultRegisterer so tests can create
+// independent instances.
+type watchMetrics struct {
+	registry    *prometheus.Registry
+	latency     *prometheus.GaugeVec
+	success     *prometheus.GaugeVec
+	cycleNumber prometheus.Gauge
+}
+
+// newWatchMetrics builds a watchMetrics with its gauges registered and ready
+// to observe cycles.
+func newWatchMetrics() *watchMetrics {
+	m := &watchMetrics{
+		registry: prometheus.NewRegistry(),
+		latency: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "asg_curl_request_duration_seconds",
+			Help: "Duration of the most recent request to an instance, in seconds.",
+		}, []string{"instance_id"}),
+		success: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "asg_curl_request_success",
+			Help: "1 if the most recent request to an instance was healthy, 0 otherwise.",
+		}, []string{"instance_id"}),
+		cycleNumber: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "asg_curl_watch_cycle",
+			Help: "Number of watch cycles completed so far.",
+		}),
+	}
+	m.registry.MustRegister(m.latency, m.success, m.cycleNumber)
+	return m
+}
+
+// observe records the outcome of one watch cycle: the healthy/unhealthy
+// verdict for each result (as determined by the caller) and the cycle count.
+func (m *watchMetrics) observe(results []Result, healthy map[string]bool, cycle int) {
+	for _, r := range results {
+		if r.InstanceState != "running" {
+			continue
+		}
+		m.latency.WithLabelValues(r.InstanceID).Set(r.ResponseTime.Seconds())
+		success := 0.0
+		if healthy[r.InstanceID] {
+			success = 1
+		}
+		m.success.WithLabelValues(r.InstanceID).Set(success)
+	}
+	m.cycleNumber.Set(float64(cycle))
+}
+
+// forget removes the latency and success series for an instance that has
+// left the source, so a long-running watch process doesn't accumulate
+// stale label series for instances that no longer exist.
+func (m *watchMetrics) forget(instanceID string) {
+	m.latency.DeleteLabelValues(instanceID)
+	m.success.DeleteLabelValues(instanceID)
+}
+
+// Handler returns the HTTP handler to serve at -metrics-addr.
+func (m *watchMetrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWatchMetricsObserveAndHandler(t *testing.T) {
+	m := newWatchMetrics()
+	results := []Result{
+		{InstanceID: "i-1", InstanceState: "running", ResponseTime: 150 * time.Millisecond},
+		{InstanceID: "i-2", InstanceState: "stopped"},
+	}
+	healthy := map[string]bool{"i-1": true}
+	m.observe(results, healthy, 1)
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, req)
+
+	body := rec.Body.String()
+	if !strings.Contains(body, `asg_curl_request_success{instance_id="i-1"} 1`) {
+		t.Errorf("expected healthy instance to report success 1, got: %s", body)
+	}
+	if !strings.Contains(body, "asg_curl_watch_cycle 1") {
+		t.Errorf("expected cycle gauge to report 1, got: %s", body)
+	}
+	if strings.Contains(body, `instance_id="i-2"`) {
+		t.Errorf("expected non-running instance to be skipped, got: %s", body)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// jsonResult is the stable, machine-consumable shape each Result is
+// marshaled to in json/ndjson output modes.
+type jsonResult struct {
+	InstanceID      string  `json:"instance_id"`
+	IP              string  `json:"ip"`
+	LaunchTime      string  `json:"launch_time"`
+	State           string  `json:"state"`
+	ResponseTimeMs  float64 `json:"response_time_ms"`
+	HTTPStatus      int     `json:"http_status"`
+	Error           string  `json:"error,omitempty"`
+	TimingDNSMs     float64 `json:"timing_dns_ms"`
+	TimingConnectMs float64 `json:"timing_connect_ms"`
+	TimingTLSMs     float64 `json:"timing_tls_ms"`
+	TimingTTFBMs    float64 `json:"timing_ttfb_ms"`
+	TimingTotalMs   float64 `json:"timing_total_ms"`
+}
+
+func toJSONResult(inst Result) jsonResult {
+	errMsg := ""
+	if inst.Error != nil {
+		errMsg = inst.Error.Error()
+	}
+	return jsonResult{
+		InstanceID:      inst.InstanceID,
+		IP:              inst.IP,
+		LaunchTime:      inst.LaunchTime.Format(time.RFC3339),
+		State:           inst.InstanceState,
+		ResponseTimeMs:  inst.ResponseTime.Seconds() * 1000,
+		HTTPStatus:      inst.HTTPStatus,
+		Error:           errMsg,
+		TimingDNSMs:     inst.Timing.DNS.Seconds() * 1000,
+		TimingConnectMs: inst.Timing.Connect.Seconds() * 1000,
+		TimingTLSMs:     inst.Timing.TLS.Seconds() * 1000,
+		TimingTTFBMs:    inst.Timing.TTFB.Seconds() * 1000,
+		TimingTotalMs:   inst.Timing.Total.Seconds() * 1000,
+	}
+}
+
+// printResults renders results in the requested format to stdout.
+func printResults(format string, results []Result) error {
+	switch format {
+	case "", "table":
+		printTable(results)
+		return nil
+	case "json":
+		return printJSON(results)
+	case "ndjson":
+		return printNDJSON(results)
+	case "csv":
+		return printCSV(results)
+	default:
+		return fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+func printTable(results []Result) {
+	fmt.Printf("\n%-20s %-15s %-25s %-12s %-15s %s\n", "Instance ID", "IP", "Launch Time", "State", "Resp Time", "Status")
+	for _, inst := range results {
+		status := "OK"
+		if inst.InstanceState != "running" {
+			status = "Skipped"
+		} else if inst.Error != nil {
+			status = inst.Error.Error()
+		}
+		fmt.Printf("%-20s %-15s %-25s %-12s %-15s %s\n",
+			inst.InstanceID,
+			inst.IP,
+			inst.LaunchTime.Format(time.RFC3339),
+			inst.InstanceState,
+			inst.ResponseTime,
+			status,
+		)
+	}
+}
+
+func printJSON(results []Result) error {
+	rows := make([]jsonResult, len(results))
+	for i, inst := range results {
+		rows[i] = toJSONResult(inst)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+func printNDJSON(results []Result) error {
+	enc := json.NewEncoder(os.Stdout)
+	for _, inst := range results {
+		if err := enc.Encode(toJSONResult(inst)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func printCSV(results []Result) error {
+	w := csv.NewWriter(os.Stdout)
+
+	header := []string{"instance_id", "ip", "launch_time", "state", "response_time_ms", "http_status", "error",
+		"timing_dns_ms", "timing_connect_ms", "timing_tls_ms", "timing_ttfb_ms", "timing_total_ms"}
+	if err := w.Write(header); err != nil {
+		return err
+	}
+
+	for _, inst := range results {
+		r := toJSONResult(inst)
+		row := []string{
+			r.InstanceID,
+			r.IP,
+			r.LaunchTime,
+			r.State,
+			strconv.FormatFloat(r.ResponseTimeMs, 'f', -1, 64),
+			strconv.Itoa(r.HTTPStatus),
+			r.Error,
+			strconv.FormatFloat(r.TimingDNSMs, 'f', -1, 64),
+			strconv.FormatFloat(r.TimingConnectMs, 'f', -1, 64),
+			strconv.FormatFloat(r.TimingTLSMs, 'f', -1, 64),
+			strconv.FormatFloat(r.TimingTTFBMs, 'f', -1, 64),
+			strconv.FormatFloat(r.TimingTotalMs, 'f', -1, 64),
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
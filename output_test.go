@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func captureStdout(t *testing.T, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+
+	done := make(chan struct{})
+	var buf bytes.Buffer
+	go func() {
+		_, _ = buf.ReadFrom(r)
+		close(done)
+	}()
+
+	fn()
+	w.Close()
+	<-done
+	os.Stdout = stdout
+	return buf.String()
+}
+
+func TestPrintResultsJSON(t *testing.T) {
+	results := []Result{
+		{InstanceID: "i-1", IP: "10.0.0.1", LaunchTime: time.Unix(0, 0), ResponseTime: 100 * time.Millisecond, HTTPStatus: 200, InstanceState: "running"},
+		{InstanceID: "i-2", IP: "10.0.0.2", LaunchTime: time.Unix(0, 0), Error: errors.New("fail"), InstanceState: "running"},
+	}
+
+	output := captureStdout(t, func() {
+		if err := printResults("json", results); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	var rows []jsonResult
+	if err := json.Unmarshal([]byte(output), &rows); err != nil {
+		t.Fatalf("failed to unmarshal JSON output: %v\noutput: %s", err, output)
+	}
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+	if rows[0].InstanceID != "i-1" || rows[0].HTTPStatus != 200 {
+		t.Errorf("unexpected first row: %+v", rows[0])
+	}
+	if rows[1].Error != "fail" {
+		t.Errorf("expected error 'fail', got %q", rows[1].Error)
+	}
+}
+
+func TestPrintResultsNDJSON(t *testing.T) {
+	results := []Result{
+		{InstanceID: "i-1", IP: "10.0.0.1", LaunchTime: time.Unix(0, 0), InstanceState: "running"},
+		{InstanceID: "i-2", IP: "10.0.0.2", LaunchTime: time.Unix(0, 0), InstanceState: "running"},
+	}
+
+	output := captureStdout(t, func() {
+		if err := printResults("ndjson", results); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), output)
+	}
+	var row jsonResult
+	if err := json.Unmarshal([]byte(lines[0]), &row); err != nil {
+		t.Fatalf("failed to unmarshal line: %v", err)
+	}
+}
+
+func TestPrintResultsCSV(t *testing.T) {
+	results := []Result{
+		{InstanceID: "i-1", IP: "10.0.0.1", LaunchTime: time.Unix(0, 0), HTTPStatus: 200, InstanceState: "running"},
+	}
+
+	output := captureStdout(t, func() {
+		if err := printResults("csv", results); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+
+	if !strings.Contains(output, "instance_id,ip,launch_time") {
+		t.Errorf("missing CSV header: %s", output)
+	}
+	if !strings.Contains(output, "i-1,10.0.0.1") {
+		t.Errorf("missing data row: %s", output)
+	}
+}
+
+func TestPrintResultsUnknownFormat(t *testing.T) {
+	err := printResults("xml", nil)
+	if err == nil || !strings.Contains(err.Error(), "unknown output format") {
+		t.Errorf("expected unknown output format error, got %v", err)
+	}
+}
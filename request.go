@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// performRequest drives one instance's requests to completion, retrying up
+// to cfg.Retries times on network errors or retryable HTTP statuses with
+// exponential backoff. The returned Result's top-level fields reflect the
+// final attempt; Attempts preserves the full history.
+func performRequest(cfg *Config, client *http.Client, limiter *rate.Limiter, inst Result) Result {
+	maxAttempts := cfg.Retries + 1
+	result := inst
+	var attempts []AttemptResult
+
+	for attemptNum := 1; attemptNum <= maxAttempts; attemptNum++ {
+		if limiter != nil {
+			_ = limiter.Wait(context.Background())
+		}
+		if attemptNum > 1 {
+			time.Sleep(backoffWithJitter(cfg.RetryBackoff, cfg.RetryBackoffMax, attemptNum-1))
+		}
+
+		status, timing, body, err := doAttempt(cfg, client, inst)
+		attempts = append(attempts, AttemptResult{
+			Number:     attemptNum,
+			HTTPStatus: status,
+			Error:      err,
+			Timing:     timing,
+		})
+
+		result = inst
+		result.HTTPStatus = status
+		result.Timing = timing
+		result.ResponseTime = timing.Total
+		result.Error = err
+		result.Attempts = attempts
+		result.body = body
+
+		if attemptNum == maxAttempts || !shouldRetry(err, status, cfg.retryStatusSet) {
+			break
+		}
+	}
+
+	return result
+}
+
+// doAttempt performs a single HTTP request to inst and returns its status
+// code, timing breakdown, response body and any error. It rebuilds the
+// request body from scratch each call since streamed bodies (multipart,
+// files) are single-use. The response body is only retained in memory when
+// cfg.ExpectBodyRegex is set, since watch mode's health check is the only
+// caller that needs it.
+func doAttempt(cfg *Config, client *http.Client, inst Result) (int, Timing, []byte, error) {
+	start := time.Now()
+	protocol := "http"
+	if cfg.TLSEnabled {
+		protocol = "https"
+	}
+	host, port := inst.IP, cfg.Port
+	if inst.dialAddr != "" {
+		if h, p, splitErr := net.SplitHostPort(inst.dialAddr); splitErr == nil {
+			host, port = h, p
+		}
+	}
+	url := fmt.Sprintf("%s://%s:%s%s", protocol, host, port, cfg.Path)
+
+	bodyReader, bodyContentType, bodyLength, err := buildRequestBody(cfg)
+	if err != nil {
+		return 0, Timing{}, nil, err
+	}
+	if bodyReader != nil {
+		defer bodyReader.Close()
+	}
+
+	method := "GET"
+	var reqBody io.Reader
+	if bodyReader != nil {
+		method = "POST"
+		reqBody = bodyReader
+	}
+	req, err := http.NewRequest(method, url, reqBody)
+	if err != nil {
+		return 0, Timing{}, nil, err
+	}
+	if bodyContentType != "" {
+		req.Header.Set("Content-Type", bodyContentType)
+	}
+	if bodyLength >= 0 {
+		req.ContentLength = bodyLength
+	}
+	for k, v := range cfg.Headers {
+		req.Header.Set(k, v)
+	}
+
+	timing := &Timing{}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), newClientTrace(timing, start)))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, *timing, nil, err
+	}
+	defer resp.Body.Close()
+
+	var respBody []byte
+	if cfg.ExpectBodyRegex != "" {
+		respBody, err = io.ReadAll(resp.Body)
+	} else {
+		_, err = io.Copy(io.Discard, resp.Body)
+	}
+	if err != nil {
+		return resp.StatusCode, *timing, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	timing.Total = time.Since(start)
+	return resp.StatusCode, *timing, respBody, nil
+}
+
+// newClientTrace builds an httptrace.ClientTrace that fills in timing as each
+// phase of the request completes. start is the time the request began, used
+// as the reference point for every phase's elapsed duration.
+func newClientTrace(timing *Timing, start time.Time) *httptrace.ClientTrace {
+	var dnsStart, connectStart, tlsStart time.Time
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			timing.DNS = time.Since(dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			connectStart = time.Now()
+		},
+		ConnectDone: func(string, string, error) {
+			timing.Connect = time.Since(connectStart)
+		},
+		TLSHandshakeStart: func() {
+			tlsStart = time.Now()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			timing.TLS = time.Since(tlsStart)
+		},
+		GotFirstResponseByte: func() {
+			timing.TTFB = time.Since(start)
+		},
+	}
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultRetryStatuses is the set of HTTP status codes that are retried
+// when -retries is set, unless overridden with -retry-status.
+const defaultRetryStatuses = "429,500,502,503,504"
+
+// AttemptResult records the outcome of a single attempt at a request,
+// including retries. Result.Attempts preserves the full history so a
+// reader can see which attempt ultimately succeeded (or why none did).
+type AttemptResult struct {
+	Number     int
+	HTTPStatus int
+	Error      error
+	Timing     Timing
+}
+
+// parseStatusSet parses a comma-separated list of HTTP status codes and/or
+// ranges (e.g. "429,500-599") into a set for fast membership checks.
+func parseStatusSet(raw string) (map[int]bool, error) {
+	set := make(map[int]bool)
+	for _, part := range splitAndTrim(raw, ",") {
+		if part == "" {
+			continue
+		}
+		if lo, hi, ok := strings.Cut(part, "-"); ok {
+			loN, err := strconv.Atoi(strings.TrimSpace(lo))
+			if err != nil {
+				return nil, fmt.Errorf("invalid status range %q: %w", part, err)
+			}
+			hiN, err := strconv.Atoi(strings.TrimSpace(hi))
+			if err != nil {
+				return nil, fmt.Errorf("invalid status range %q: %w", part, err)
+			}
+			for n := loN; n <= hiN; n++ {
+				set[n] = true
+			}
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid status code %q: %w", part, err)
+		}
+		set[n] = true
+	}
+	return set, nil
+}
+
+// shouldRetry reports whether an attempt should be retried: network errors
+// are always retried, HTTP responses only when their status is in statuses.
+func shouldRetry(err error, httpStatus int, statuses map[int]bool) bool {
+	if err != nil {
+		return true
+	}
+	return statuses[httpStatus]
+}
+
+// backoffWithJitter returns the delay before retry attempt n (1-indexed),
+// growing exponentially from base and capped at max, with up to 50% jitter
+// to avoid every goroutine retrying in lockstep.
+func backoffWithJitter(base, max time.Duration, attempt int) time.Duration {
+	delay := base
+	for i := 1; i < attempt && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	jitter := time.Duration(rand.Int63n(int64(delay)/2 + 1))
+	return delay/2 + jitter
+}
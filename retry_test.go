@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseStatusSet(t *testing.T) {
+	set, err := parseStatusSet("429,500-502")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, code := range []int{429, 500, 501, 502} {
+		if !set[code] {
+			t.Errorf("expected %d to be in status set", code)
+		}
+	}
+	if set[503] {
+		t.Errorf("did not expect 503 in status set")
+	}
+}
+
+func TestParseStatusSetInvalid(t *testing.T) {
+	if _, err := parseStatusSet("not-a-code"); err == nil {
+		t.Error("expected error for invalid status code")
+	}
+}
+
+func TestShouldRetry(t *testing.T) {
+	statuses := map[int]bool{429: true, 503: true}
+	if !shouldRetry(errors.New("boom"), 0, statuses) {
+		t.Error("expected network errors to always be retried")
+	}
+	if !shouldRetry(nil, 503, statuses) {
+		t.Error("expected 503 to be retried")
+	}
+	if shouldRetry(nil, 200, statuses) {
+		t.Error("did not expect 200 to be retried")
+	}
+}
+
+func TestBackoffWithJitterCapped(t *testing.T) {
+	max := 1 * time.Second
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := backoffWithJitter(100*time.Millisecond, max, attempt)
+		if d < 0 || d > max {
+			t.Errorf("attempt %d: backoff %v out of bounds [0, %v]", attempt, d, max)
+		}
+	}
+}
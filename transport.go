@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// requestTransport resolves how to actually reach an instance when it isn't
+// directly IP-reachable, e.g. an SSM port-forward or an SSH bastion jump.
+// A nil requestTransport means connect straight to inst.IP, as before -via
+// existed.
+type requestTransport interface {
+	// Prepare returns the *http.Client to use for inst, an updated copy of
+	// inst (e.g. with dialAddr set to a locally forwarded port), and a
+	// cleanup func the caller must invoke once it's done with inst.
+	Prepare(ctx context.Context, inst Result) (*http.Client, Result, func() error, error)
+	// Close releases any resources shared across instances, such as a
+	// bastion's SSH connection.
+	Close() error
+}
+
+// newTransport builds the requestTransport selected by cfg.Via. It returns a
+// nil transport (and nil error) for the default "direct" transport.
+func newTransport(awsCfg aws.Config, cfg *Config) (requestTransport, error) {
+	switch cfg.Via {
+	case "", "direct":
+		return nil, nil
+	case "ssm":
+		return newSSMTransport(awsCfg, cfg), nil
+	case "bastion":
+		return newBastionTransport(cfg)
+	default:
+		return nil, fmt.Errorf("unknown transport %q (want %s)", cfg.Via, strings.Join(supportedTransports, ", "))
+	}
+}
+
+// ssmTransport reaches each instance via an AWS Systems Manager Session
+// Manager port-forwarding session, so instances in private subnets don't
+// need direct IP reachability. Each instance gets its own locally forwarded
+// port, since a single SSM session only forwards to one remote target.
+type ssmTransport struct {
+	client     *ssm.Client
+	region     string
+	remotePort string
+	timeout    time.Duration
+
+	mu       sync.Mutex
+	nextPort int
+}
+
+func newSSMTransport(awsCfg aws.Config, cfg *Config) *ssmTransport {
+	return &ssmTransport{
+		client:     ssm.NewFromConfig(awsCfg),
+		region:     awsCfg.Region,
+		remotePort: cfg.Port,
+		timeout:    cfg.Timeout,
+		nextPort:   18080,
+	}
+}
+
+func (t *ssmTransport) allocatePort() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	port := t.nextPort
+	t.nextPort++
+	return port
+}
+
+// Prepare starts an SSM port-forwarding session to inst, using the
+// session-manager-plugin binary (the same one the AWS CLI relies on) to
+// drive the SSM data channel, and waits for the local port to come up
+// before handing back a client.
+func (t *ssmTransport) Prepare(ctx context.Context, inst Result) (*http.Client, Result, func() error, error) {
+	localPort := t.allocatePort()
+	startSessionInput := &ssm.StartSessionInput{
+		Target:       aws.String(inst.InstanceID),
+		DocumentName: aws.String("AWS-StartPortForwardingSession"),
+		Parameters: map[string][]string{
+			"portNumber":      {t.remotePort},
+			"localPortNumber": {strconv.Itoa(localPort)},
+		},
+	}
+	out, err := t.client.StartSession(ctx, startSessionInput)
+	if err != nil {
+		return nil, Result{}, nil, fmt.Errorf("starting SSM session for %s: %w", inst.InstanceID, err)
+	}
+
+	sessionJSON, err := json.Marshal(out)
+	if err != nil {
+		return nil, Result{}, nil, fmt.Errorf("marshaling SSM session response: %w", err)
+	}
+
+	paramsJSON, err := json.Marshal(startSessionInput)
+	if err != nil {
+		return nil, Result{}, nil, fmt.Errorf("marshaling SSM session request for %s: %w", inst.InstanceID, err)
+	}
+
+	endpoint := fmt.Sprintf("https://ssm.%s.amazonaws.com", t.region)
+	cmd := exec.CommandContext(ctx, "session-manager-plugin", string(sessionJSON), t.region, "StartSession", "", string(paramsJSON), endpoint)
+	if err := cmd.Start(); err != nil {
+		return nil, Result{}, nil, fmt.Errorf("starting session-manager-plugin for %s: %w", inst.InstanceID, err)
+	}
+
+	if err := waitForPort(ctx, localPort, t.timeout); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, Result{}, nil, fmt.Errorf("waiting for SSM port forward to %s: %w", inst.InstanceID, err)
+	}
+
+	reqInst := inst
+	reqInst.dialAddr = fmt.Sprintf("127.0.0.1:%d", localPort)
+
+	client := &http.Client{Timeout: t.timeout}
+	cleanup := func() error {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		_, err := t.client.TerminateSession(context.Background(), &ssm.TerminateSessionInput{SessionId: out.SessionId})
+		return err
+	}
+	return client, reqInst, cleanup, nil
+}
+
+func (t *ssmTransport) Close() error { return nil }
+
+// waitForPort polls addr 127.0.0.1:port until it accepts a connection or
+// timeout elapses, since the session-manager-plugin forwards the port
+// asynchronously after cmd.Start returns.
+func waitForPort(ctx context.Context, port int, timeout time.Duration) error {
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.DialTimeout("tcp", addr, 200*time.Millisecond)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("timed out waiting for %s: %w", addr, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// bastionTransport reaches instances by dialing through an SSH jump host
+// instead of connecting directly, for ASGs in private subnets reachable
+// only from inside the VPC. Unlike ssmTransport, a single SSH connection is
+// shared across all instances: the bastion can already route to each
+// instance's private IP, so only the TCP dial needs to go through the
+// tunnel.
+type bastionTransport struct {
+	sshClient *ssh.Client
+	client    *http.Client
+}
+
+func newBastionTransport(cfg *Config) (*bastionTransport, error) {
+	user, hostport, err := parseBastionAddr(cfg.BastionAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := sshAuthMethods(cfg.SSHKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	hostKeyCallback, err := bastionHostKeyCallback(cfg.InsecureSkipHostKeyCheck)
+	if err != nil {
+		return nil, err
+	}
+
+	sshCfg := &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         cfg.Timeout,
+	}
+
+	sshClient, err := ssh.Dial("tcp", hostport, sshCfg)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to bastion %s: %w", hostport, err)
+	}
+
+	httpTransport := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return sshClient.Dial(network, addr)
+		},
+	}
+	return &bastionTransport{
+		sshClient: sshClient,
+		client:    &http.Client{Timeout: cfg.Timeout, Transport: httpTransport},
+	}, nil
+}
+
+func (t *bastionTransport) Prepare(ctx context.Context, inst Result) (*http.Client, Result, func() error, error) {
+	return t.client, inst, func() error { return nil }, nil
+}
+
+func (t *bastionTransport) Close() error { return t.sshClient.Close() }
+
+// parseBastionAddr splits a "user@host" or "user@host:port" -bastion value,
+// defaulting to port 22 when none is given.
+func parseBastionAddr(addr string) (user, hostport string, err error) {
+	parts := strings.SplitN(addr, "@", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("-bastion must be user@host, got %q", addr)
+	}
+	hostport = parts[1]
+	if _, _, err := net.SplitHostPort(hostport); err != nil {
+		hostport = net.JoinHostPort(hostport, "22")
+	}
+	return parts[0], hostport, nil
+}
+
+// sshAuthMethods builds the SSH auth methods for the bastion connection:
+// ssh-agent (via SSH_AUTH_SOCK) when available, plus an explicit private
+// key when -ssh-key is set.
+func sshAuthMethods(keyPath string) ([]ssh.AuthMethod, error) {
+	var methods []ssh.AuthMethod
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			methods = append(methods, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+		}
+	}
+
+	if keyPath != "" {
+		key, err := os.ReadFile(keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading -ssh-key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("parsing -ssh-key: %w", err)
+		}
+		methods = append(methods, ssh.PublicKeys(signer))
+	}
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no SSH authentication available: set -ssh-key or run an ssh-agent (SSH_AUTH_SOCK)")
+	}
+	return methods, nil
+}
+
+// bastionHostKeyCallback verifies the bastion's host key against
+// ~/.ssh/known_hosts. It only falls back to accepting any host key when the
+// caller explicitly opted in via -insecure-skip-host-key-check; otherwise a
+// missing or unreadable known_hosts is a hard error, since silently
+// skipping verification would let a MITM'd jump host intercept every
+// request made "through" it.
+func bastionHostKeyCallback(insecureSkipHostKeyCheck bool) (ssh.HostKeyCallback, error) {
+	home, err := os.UserHomeDir()
+	if err == nil {
+		if cb, err := knownhosts.New(filepath.Join(home, ".ssh", "known_hosts")); err == nil {
+			return cb, nil
+		}
+	}
+	if !insecureSkipHostKeyCheck {
+		return nil, fmt.Errorf("no ~/.ssh/known_hosts found to verify the bastion host key; pass -insecure-skip-host-key-check to accept any host key")
+	}
+	log.Printf("warning: -insecure-skip-host-key-check set, bastion host key will not be verified")
+	return ssh.InsecureIgnoreHostKey(), nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestNewTransportDirect(t *testing.T) {
+	transport, err := newTransport(aws.Config{}, &Config{Via: "direct"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if transport != nil {
+		t.Errorf("expected nil transport for -via direct, got %v", transport)
+	}
+}
+
+func TestNewTransportUnknown(t *testing.T) {
+	_, err := newTransport(aws.Config{}, &Config{Via: "vpn"})
+	if err == nil || !strings.Contains(err.Error(), "unknown transport") {
+		t.Errorf("expected unknown transport error, got %v", err)
+	}
+}
+
+func TestParseBastionAddr(t *testing.T) {
+	user, hostport, err := parseBastionAddr("ec2-user@bastion.example.com")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user != "ec2-user" || hostport != "bastion.example.com:22" {
+		t.Errorf("expected ec2-user/bastion.example.com:22, got %s/%s", user, hostport)
+	}
+
+	user, hostport, err = parseBastionAddr("root@10.0.0.1:2222")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if user != "root" || hostport != "10.0.0.1:2222" {
+		t.Errorf("expected root/10.0.0.1:2222, got %s/%s", user, hostport)
+	}
+}
+
+func TestParseBastionAddrInvalid(t *testing.T) {
+	if _, _, err := parseBastionAddr("no-at-sign"); err == nil {
+		t.Error("expected error for missing user@host separator")
+	}
+}
+
+func TestSSHAuthMethodsNoneAvailable(t *testing.T) {
+	oldSock := os.Getenv("SSH_AUTH_SOCK")
+	os.Unsetenv("SSH_AUTH_SOCK")
+	defer os.Setenv("SSH_AUTH_SOCK", oldSock)
+
+	if _, err := sshAuthMethods(""); err == nil {
+		t.Error("expected error when no ssh-agent or -ssh-key is available")
+	}
+}
+
+func TestSSHAuthMethodsMissingKeyFile(t *testing.T) {
+	if _, err := sshAuthMethods("/no/such/key"); err == nil || !strings.Contains(err.Error(), "reading -ssh-key") {
+		t.Errorf("expected reading -ssh-key error, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// runWatch turns the tool into a continuous poller: every cfg.Watch it
+// re-discovers instances, re-issues requests, prints results, logs any
+// instances that entered or left the source, and tracks consecutive health
+// failures per instance. It returns an error once any instance has failed
+// cfg.FailThreshold cycles in a row.
+func runWatch(ctx context.Context, cfg *Config, source InstanceSource, instances []Result) error {
+	var metrics *watchMetrics
+	if cfg.MetricsAddr != "" {
+		metrics = newWatchMetrics()
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", metrics.Handler())
+		server := &http.Server{Addr: cfg.MetricsAddr, Handler: mux}
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("metrics server error: %v", err)
+			}
+		}()
+	}
+
+	known := map[string]bool{}
+	consecutiveFailures := map[string]int{}
+	ticker := time.NewTicker(cfg.Watch)
+	defer ticker.Stop()
+
+	for cycle := 1; ; cycle++ {
+		current := map[string]bool{}
+		for _, inst := range instances {
+			current[inst.InstanceID] = true
+			if !known[inst.InstanceID] {
+				fmt.Printf("==> [cycle %d] instance entered: %s (%s)\n", cycle, inst.InstanceID, inst.IP)
+			}
+		}
+		for id := range known {
+			if !current[id] {
+				fmt.Printf("==> [cycle %d] instance left: %s\n", cycle, id)
+				delete(consecutiveFailures, id)
+				if metrics != nil {
+					metrics.forget(id)
+				}
+			}
+		}
+		known = current
+
+		results := makeRequests(cfg, instances)
+		if err := printResults(cfg.OutputFormat, results); err != nil {
+			return err
+		}
+
+		healthy := map[string]bool{}
+		for _, r := range results {
+			if r.InstanceState != "running" {
+				continue
+			}
+			ok := isHealthy(cfg, r)
+			healthy[r.InstanceID] = ok
+			if ok {
+				consecutiveFailures[r.InstanceID] = 0
+				continue
+			}
+			consecutiveFailures[r.InstanceID]++
+			if consecutiveFailures[r.InstanceID] >= cfg.FailThreshold {
+				return fmt.Errorf("instance %s failed %d consecutive cycles (fail-threshold %d)",
+					r.InstanceID, consecutiveFailures[r.InstanceID], cfg.FailThreshold)
+			}
+		}
+
+		if metrics != nil {
+			metrics.observe(results, healthy, cycle)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		next, err := source.Discover(ctx)
+		if err != nil {
+			return fmt.Errorf("error discovering instances: %w", err)
+		}
+		instances = next
+	}
+}
+
+// isHealthy reports whether a result meets the configured health-check
+// criteria: no request error, and (if set) a matching -expect-status and/or
+// -expect-body-regex.
+func isHealthy(cfg *Config, r Result) bool {
+	if r.Error != nil {
+		return false
+	}
+	if cfg.ExpectStatus != 0 && r.HTTPStatus != cfg.ExpectStatus {
+		return false
+	}
+	if cfg.expectBodyRegex != nil && !cfg.expectBodyRegex.Match(r.body) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestIsHealthy(t *testing.T) {
+	cfg := &Config{}
+	if !isHealthy(cfg, Result{HTTPStatus: 500}) {
+		t.Error("expected no checks configured to mean healthy regardless of status")
+	}
+
+	cfg = &Config{ExpectStatus: 200}
+	if isHealthy(cfg, Result{HTTPStatus: 500}) {
+		t.Error("expected status mismatch to be unhealthy")
+	}
+	if !isHealthy(cfg, Result{HTTPStatus: 200}) {
+		t.Error("expected matching status to be healthy")
+	}
+
+	re, err := regexp.Compile("^ok$")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg = &Config{expectBodyRegex: re}
+	if isHealthy(cfg, Result{HTTPStatus: 200, body: []byte("fail")}) {
+		t.Error("expected body mismatch to be unhealthy")
+	}
+	if !isHealthy(cfg, Result{HTTPStatus: 200, body: []byte("ok")}) {
+		t.Error("expected matching body to be healthy")
+	}
+}
+
+// fakeInstanceSource always returns the same fixed instance list, letting
+// tests drive runWatch without a real cloud provider.
+type fakeInstanceSource struct {
+	instances []Result
+}
+
+func (f *fakeInstanceSource) Discover(ctx context.Context) ([]Result, error) {
+	return f.instances, nil
+}
+
+func TestRunWatchExitsOnFailThreshold(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	host, port, _ := strings.Cut(strings.TrimPrefix(server.URL, "http://"), ":")
+	cfg := &Config{
+		Timeout:       time.Second,
+		Port:          port,
+		Path:          "/",
+		OutputFormat:  "table",
+		FailThreshold: 2,
+		Watch:         time.Millisecond,
+		ExpectStatus:  200,
+	}
+	source := &fakeInstanceSource{instances: []Result{{InstanceID: "i-1", IP: host, InstanceState: "running"}}}
+
+	err := runWatch(context.Background(), cfg, source, source.instances)
+	if err == nil || !strings.Contains(err.Error(), "failed 2 consecutive cycles") {
+		t.Errorf("expected fail-threshold error, got %v", err)
+	}
+}